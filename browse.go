@@ -0,0 +1,147 @@
+package tube
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"sort"
+	"strings"
+	"time"
+)
+
+// options controlling the opt-in directory-listing behavior of StaticDir,
+// StaticFS and BrowsableDir; the zero value keeps listings disabled, which
+// is the default for existing StaticDir/StaticFS callers
+type BrowseOptions struct {
+	// render an HTML (or JSON) index for directories lacking index.html
+	Browse bool
+	// list directories even when an index.html is present
+	IgnoreIndexes bool
+	// custom listing template; receives a browseListing. Defaults to
+	// defaultBrowseTemplate when nil
+	Template *template.Template
+}
+
+type browseEntry struct {
+	Name    string
+	IsDir   bool
+	Size    int64
+	ModTime time.Time
+}
+
+func (e browseEntry) SizeHuman() string {
+	if e.IsDir {
+		return "-"
+	}
+	return humanSize(e.Size)
+}
+
+func (e browseEntry) ModTimeHuman() string {
+	return e.ModTime.Format("2006-01-02 15:04:05")
+}
+
+func humanSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// data made available to BrowseOptions.Template
+type browseListing struct {
+	Path      string
+	HasParent bool
+	Entries   []browseEntry
+}
+
+var defaultBrowseTemplate = template.Must(template.New("browse").Parse(`<!DOCTYPE html>
+<html>
+<head><title>Index of {{.Path}}</title></head>
+<body>
+<h1>Index of {{.Path}}</h1>
+<table>
+<tr><th>Name</th><th>Size</th><th>Modified</th></tr>
+{{if .HasParent}}<tr><td><a href="../">../</a></td><td>-</td><td>-</td></tr>{{end}}
+{{range .Entries}}<tr><td><a href="{{.Name}}{{if .IsDir}}/{{end}}">{{.Name}}{{if .IsDir}}/{{end}}</a></td><td>{{.SizeHuman}}</td><td>{{.ModTimeHuman}}</td></tr>
+{{end}}
+</table>
+</body>
+</html>
+`))
+
+// renders a directory index for reqPath, honoring ?sort=name|size|modtime
+// and ?order=asc|desc, and returning JSON instead of HTML when the client
+// sends Accept: application/json
+func (router *Router) serveBrowseListing(d *Data, fsys fs.FS, reqPath string, opts BrowseOptions) {
+	dirEntries, err := fs.ReadDir(fsys, reqPath)
+	if err != nil {
+		d.Error(fmt.Errorf("unreadable directory listing: %w", err))
+		return
+	}
+
+	entries := make([]browseEntry, 0, len(dirEntries))
+	for _, de := range dirEntries {
+		info, err := de.Info()
+		if err != nil {
+			continue
+		}
+		entries = append(entries, browseEntry{
+			Name:    de.Name(),
+			IsDir:   de.IsDir(),
+			Size:    info.Size(),
+			ModTime: info.ModTime(),
+		})
+	}
+
+	sortBrowseEntries(entries, d.Request.URL.Query().Get("sort"), d.Request.URL.Query().Get("order"))
+
+	if strings.Contains(d.Request.Header.Get("Accept"), "application/json") {
+		d.Writer.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(d.Writer).Encode(entries)
+		return
+	}
+
+	tmpl := opts.Template
+	if tmpl == nil {
+		tmpl = defaultBrowseTemplate
+	}
+
+	listing := browseListing{
+		Path:      d.Request.URL.Path,
+		HasParent: reqPath != ".",
+		Entries:   entries,
+	}
+	if listing.Path == "" {
+		listing.Path = "/"
+	}
+
+	d.Writer.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := tmpl.Execute(d.Writer, listing); err != nil {
+		d.Error(fmt.Errorf("browse template: %w", err))
+	}
+}
+
+func sortBrowseEntries(entries []browseEntry, by, order string) {
+	less := func(i, j int) bool {
+		switch by {
+		case "size":
+			return entries[i].Size < entries[j].Size
+		case "modtime":
+			return entries[i].ModTime.Before(entries[j].ModTime)
+		default:
+			return entries[i].Name < entries[j].Name
+		}
+	}
+	if order == "desc" {
+		asc := less
+		less = func(i, j int) bool { return asc(j, i) }
+	}
+	sort.Slice(entries, less)
+}
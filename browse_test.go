@@ -0,0 +1,96 @@
+package tube
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestBrowsableDirListing(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(filepath.Join(dir, "sub", "a.txt"), []byte("a"), 0644)
+	os.WriteFile(filepath.Join(dir, "sub", "b.txt"), []byte("bbb"), 0644)
+
+	router := NewRouter()
+	router.BrowsableDir("/assets", dir, BrowseOptions{})
+
+	req := httptest.NewRequest("GET", "/assets/sub/", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	body := w.Body.String()
+	if !strings.Contains(body, "a.txt") || !strings.Contains(body, "b.txt") {
+		t.Fatalf("expected listing to contain both files, got %q", body)
+	}
+}
+
+func TestBrowsableDirRedirectsBareDirToTrailingSlash(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(filepath.Join(dir, "sub", "a.txt"), []byte("a"), 0644)
+
+	router := NewRouter()
+	router.BrowsableDir("/assets", dir, BrowseOptions{})
+
+	req := httptest.NewRequest("GET", "/assets/sub?sort=size", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusMovedPermanently {
+		t.Fatalf("expected 301 redirect to the trailing-slash form, got %d", w.Code)
+	}
+	if loc := w.Header().Get("Location"); loc != "/assets/sub/?sort=size" {
+		t.Fatalf("expected redirect to /assets/sub/?sort=size, got %q", loc)
+	}
+}
+
+func TestStaticDirListingDisabledByDefault(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(filepath.Join(dir, "sub", "a.txt"), []byte("a"), 0644)
+
+	router := NewRouter()
+	router.GET(router.Dir("/assets"), router.StaticDir(dir))
+
+	req := httptest.NewRequest("GET", "/assets/sub", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code == 200 {
+		t.Fatalf("expected listings to stay disabled for plain StaticDir, got 200")
+	}
+}
+
+func TestBrowseListingJSON(t *testing.T) {
+	fsys := fstest.MapFS{
+		"dir/file.txt": {Data: []byte("contents")},
+	}
+
+	router := NewRouter()
+	router.StaticFS("/", fsys, BrowseOptions{Browse: true})
+
+	req := httptest.NewRequest("GET", "/dir/", nil)
+	req.Header.Set("Accept", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	var entries []browseEntry
+	if err := json.Unmarshal(w.Body.Bytes(), &entries); err != nil {
+		t.Fatalf("expected valid JSON listing, got %q: %v", w.Body.String(), err)
+	}
+	if len(entries) != 1 || entries[0].Name != "file.txt" {
+		t.Fatalf("unexpected entries: %+v", entries)
+	}
+}
@@ -11,13 +11,25 @@ import (
 	"strings"
 )
 
-func readHTMLFile(fn string) (string, error) {
+func readHTMLFile(fsys fs.FS, fn string) (string, error) {
 	// remove prefixing '/' from filename for consistency as paths
 	// in include tags will likely not have them (but URLs will)
 	fn = strings.TrimPrefix(fn, "/")
+	if fn == "" {
+		fn = "."
+	}
+
+	// fs.ValidPath rejects any path containing a '..' element (or an
+	// absolute path), which is the traversal guard a filepath.Rel(root,
+	// resolved) comparison would otherwise be needed for; fs.FS
+	// implementations (zip, embed, os.DirFS, ...) enforce the same rule on
+	// Open, so this just fails fast with a clearer error
+	if !fs.ValidPath(fn) {
+		return "", fmt.Errorf("invalid path %q: %w", fn, fs.ErrNotExist)
+	}
 
 	// read file
-	b, err := os.ReadFile(fn)
+	b, err := fs.ReadFile(fsys, fn)
 	if err != nil {
 		return "", err
 	}
@@ -26,7 +38,11 @@ func readHTMLFile(fn string) (string, error) {
 	return str, nil
 }
 
-func (router *Router) parseHTML(str string, parentDir string) (string, error) {
+// deps, when non-nil, accumulates the fsys-relative path of every file read
+// while resolving str (the top-level template plus every include pulled in
+// transitively), so a caller like WatchStatic can later invalidate the
+// cached render of str whenever one of those files changes
+func (router *Router) parseHTML(fsys fs.FS, str string, parentDir string, deps *[]string) (string, error) {
 	// find and parse include tags
 	includes := regexp.MustCompile(`<!--\s*include "(.+?\.html)"\s*-->`).FindAllStringSubmatch(str, -1)
 	if len(includes) > 0 {
@@ -36,14 +52,17 @@ func (router *Router) parseHTML(str string, parentDir string) (string, error) {
 
 			// read referenced file
 			fn := path.Join(parentDir, include)
-			body, err := readHTMLFile(fn)
+			body, err := readHTMLFile(fsys, fn)
 			if err != nil {
 				return "", fmt.Errorf("unreadable include %s", err)
 			}
+			if deps != nil {
+				*deps = append(*deps, fn)
+			}
 
 			// parse it
 			nextDir := path.Join(parentDir, path.Dir(include))
-			contents, err := router.parseHTML(body, nextDir)
+			contents, err := router.parseHTML(fsys, body, nextDir, deps)
 			if err != nil {
 				return "", err
 			}
@@ -79,32 +98,36 @@ func (router *Router) parseHTML(str string, parentDir string) (string, error) {
 	return str, nil
 }
 
-func (router *Router) serveHTMLStatic(d *Data, dir string, fn string) {
+func (router *Router) serveHTMLStatic(d *Data, fsys fs.FS, dir string, fn string) {
 	fn = path.Join(dir, fn)
-	body, err := readHTMLFile(fn)
+	body, err := readHTMLFile(fsys, fn)
 	if err != nil {
 		d.Error(fmt.Errorf("unreadable static include %s", err))
 		return
 	}
-	router.serveHTML(d, true, body, dir)
+	router.serveHTML(d, fsys, true, body, dir, []string{fn})
 }
 
-func (router *Router) serveHTML(d *Data, static bool, text string, dir string) {
+// deps is the set of fsys-relative paths text was read from (just fn for
+// serveHTMLStatic, nil for handler-supplied inline HTML); it seeds the
+// dependency tracking parseHTML extends with every include it resolves
+func (router *Router) serveHTML(d *Data, fsys fs.FS, static bool, text string, dir string, deps []string) {
 	url := d.Request.URL.Path
 
 	// serve from cache if exists
 	if static {
-		router.htmlMutex.RLock()
-		html, cached := router.htmlCache[url]
-		router.htmlMutex.RUnlock()
-		if cached && !router.noCache {
+		router.core.htmlMutex.RLock()
+		html, cached := router.core.htmlCache[url]
+		router.core.htmlMutex.RUnlock()
+		if cached && !router.core.noCache {
 			io.WriteString(d.Writer, html)
 			return
 		}
 	}
 
 	// parse and serve
-	str, err := router.parseHTML(text, dir)
+	allDeps := append([]string{}, deps...)
+	str, err := router.parseHTML(fsys, text, dir, &allDeps)
 
 	if err != nil {
 		if errors.Is(err, fs.ErrNotExist) {
@@ -120,9 +143,48 @@ func (router *Router) serveHTML(d *Data, static bool, text string, dir string) {
 
 	// save to cache
 	if static {
-		router.htmlMutex.Lock()
-		router.htmlCache[url] = str
-		router.htmlMutex.Unlock()
+		router.core.htmlMutex.Lock()
+		router.core.htmlCache[url] = str
+		if !router.core.noCache {
+			router.core.htmlDeps[url] = allDeps
+		}
+		router.core.htmlMutex.Unlock()
+
+	}
+}
+
+// invalidates every cached template whose render depended, directly or via
+// an include, on relPath (an fsys-relative path); used by WatchStatic to
+// react to filesystem events
+func (router *Router) invalidateHTMLDeps(relPath string) {
+	router.core.htmlMutex.Lock()
+	var affected []string
+	for url, deps := range router.core.htmlDeps {
+		for _, dep := range deps {
+			if dep == relPath {
+				affected = append(affected, url)
+				break
+			}
+		}
+	}
+	for _, url := range affected {
+		delete(router.core.htmlDeps, url)
+		delete(router.core.htmlCache, url)
+	}
+	router.core.htmlMutex.Unlock()
 
+	if len(affected) == 0 {
+		return
+	}
+
+	router.core.routeMutex.Lock()
+	for cacheName := range router.core.routeCache {
+		for _, url := range affected {
+			if strings.HasSuffix(cacheName, " "+url) {
+				delete(router.core.routeCache, cacheName)
+				break
+			}
+		}
 	}
+	router.core.routeMutex.Unlock()
 }
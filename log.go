@@ -0,0 +1,155 @@
+package tube
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// receives a RequestLogEntry after every request the router serves (unless
+// excluded via IgnoreLogs); swap in a custom implementation with
+// Router.SetRequestLog to feed a structured logging pipeline instead of the
+// default router.logger summary
+type RequestLog interface {
+	Log(entry RequestLogEntry)
+}
+
+// per-request outcome and phase timestamps passed to RequestLog.Log
+type RequestLogEntry struct {
+	Request *http.Request
+	Status  int
+	Size    int64
+
+	Accepted       time.Time // callRoute started handling the request
+	Routed         time.Time // routing decided, the handler is about to run
+	HeaderComplete time.Time // the handler (or its default) wrote its response header
+	Flushed        time.Time // the handler returned and the response is fully written
+}
+
+// time from Accepted to Routed: callRoute's setup before the handler ran.
+// Route matching in ServeHTTP finishes before Accepted is taken, so it is
+// not included
+func (e RequestLogEntry) RoutingDuration() time.Duration {
+	return e.Routed.Sub(e.Accepted)
+}
+
+// time from handoff to the handler until its first response byte
+func (e RequestLogEntry) TimeToFirstByte() time.Duration {
+	return e.HeaderComplete.Sub(e.Routed)
+}
+
+// total time spent inside the handler, start to finish
+func (e RequestLogEntry) HandlerDuration() time.Duration {
+	return e.Flushed.Sub(e.Routed)
+}
+
+// default RequestLog: a single summary line through router.logger at
+// LOG_INFO (always for 5xx responses), with request headers added at
+// LOG_DEBUG; response headers are not logged
+type defaultRequestLog struct {
+	router *Router
+}
+
+func (l *defaultRequestLog) Log(e RequestLogEntry) {
+	if l.router.core.logLevel < LOG_INFO && e.Status < http.StatusInternalServerError {
+		return
+	}
+
+	l.router.core.logger.Printf("%s %s %d %dB routing=%s ttfb=%s total=%s",
+		e.Request.Method, e.Request.URL.RequestURI(), e.Status, e.Size,
+		e.RoutingDuration(), e.TimeToFirstByte(), e.Flushed.Sub(e.Accepted))
+
+	if l.router.core.logLevel >= LOG_DEBUG {
+		l.router.core.logger.Printf("  request headers: %v", e.Request.Header)
+	}
+}
+
+// wraps an http.ResponseWriter to count written bytes and capture the
+// first WriteHeader call, so RequestLogEntry.Status/Size stay accurate even
+// when a handler writes straight to the underlying writer (e.g.
+// http.FileServer) instead of going through Data.Status
+type ResponseLogWriter struct {
+	http.ResponseWriter
+	status      int
+	size        int64
+	wroteHeader bool
+	headerAt    time.Time
+}
+
+func newResponseLogWriter(w http.ResponseWriter) *ResponseLogWriter {
+	return &ResponseLogWriter{ResponseWriter: w, status: http.StatusOK}
+}
+
+func (w *ResponseLogWriter) WriteHeader(code int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		w.status = code
+		w.headerAt = time.Now()
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *ResponseLogWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.size += int64(n)
+	return n, err
+}
+
+// forwards the optional interfaces handlers commonly type-assert a
+// ResponseWriter for (streaming, websocket upgrades); without these,
+// wrapping would silently downgrade a writer that supported them
+
+func (w *ResponseLogWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *ResponseLogWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hj, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("tube: underlying ResponseWriter does not support Hijack")
+	}
+	return hj.Hijack()
+}
+
+func (w *ResponseLogWriter) Push(target string, opts *http.PushOptions) error {
+	p, ok := w.ResponseWriter.(http.Pusher)
+	if !ok {
+		return http.ErrNotSupported
+	}
+	return p.Push(target, opts)
+}
+
+// compiles a doublestar-style glob (where "*" matches within a path segment
+// and "**" matches across segments) into an anchored regexp
+func doublestarToRegexp(pattern string) (*regexp.Regexp, error) {
+	var b strings.Builder
+	b.WriteByte('^')
+
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; c {
+		case '*':
+			if i+1 < len(pattern) && pattern[i+1] == '*' {
+				b.WriteString(".*")
+				i++
+			} else {
+				b.WriteString("[^/]*")
+			}
+		case '?':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+
+	b.WriteByte('$')
+	return regexp.Compile(b.String())
+}
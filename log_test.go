@@ -0,0 +1,82 @@
+package tube
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+type recordingRequestLog struct {
+	mu      sync.Mutex
+	entries []RequestLogEntry
+}
+
+func (l *recordingRequestLog) Log(e RequestLogEntry) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.entries = append(l.entries, e)
+}
+
+func TestResponseLogWriterCapturesImplicitStatus(t *testing.T) {
+	router := NewRouter()
+	rec := &recordingRequestLog{}
+	router.SetRequestLog(rec)
+
+	// requests a file that doesn't exist; StaticDir hands it to the nested
+	// 404 route, whose status reaches the outer wrapped writer without the
+	// StaticDir handler ever calling its own Data.Status
+	router.GET(router.Dir("/assets"), router.StaticDir(t.TempDir()))
+
+	req := httptest.NewRequest("GET", "/assets/missing.txt", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	if len(rec.entries) == 0 {
+		t.Fatal("expected at least one log entry")
+	}
+	last := rec.entries[len(rec.entries)-1]
+	if last.Status != w.Code {
+		t.Fatalf("expected logged status %d to match response status %d", last.Status, w.Code)
+	}
+}
+
+func TestResponseLogWriterForwardsFlusher(t *testing.T) {
+	router := NewRouter()
+
+	var sawFlusher bool
+	router.GET("/stream", func(d *Data) {
+		_, sawFlusher = d.Writer.(http.Flusher)
+	})
+
+	req := httptest.NewRequest("GET", "/stream", nil)
+	w := httptest.NewRecorder() // implements http.Flusher
+	router.ServeHTTP(w, req)
+
+	if !sawFlusher {
+		t.Fatal("expected the wrapped writer to still satisfy http.Flusher")
+	}
+}
+
+func TestIgnoreLogsExcludesMatchingRequests(t *testing.T) {
+	router := NewRouter()
+	rec := &recordingRequestLog{}
+	router.SetRequestLog(rec)
+	router.IgnoreLogs([]string{"**/healthz", "**/assets/**"})
+
+	router.GET("/healthz", func(d *Data) {})
+	router.GET("/assets/@@path", func(d *Data) {})
+	router.GET("/api", func(d *Data) {})
+
+	for _, url := range []string{"/healthz", "/assets/css/site.css", "/api"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", url, nil))
+	}
+
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	if len(rec.entries) != 1 || rec.entries[0].Request.URL.Path != "/api" {
+		t.Fatalf("expected only /api to be logged, got %+v", rec.entries)
+	}
+}
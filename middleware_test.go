@@ -0,0 +1,152 @@
+package tube
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestUseAppliesMiddlewareInRegistrationOrder(t *testing.T) {
+	var order []string
+
+	tag := func(name string) func(Callback) Callback {
+		return func(next Callback) Callback {
+			return func(d *Data) {
+				order = append(order, name)
+				next(d)
+			}
+		}
+	}
+
+	router := NewRouter()
+	router.Use(tag("first"), tag("second"))
+	router.GET("/hello", func(d *Data) { order = append(order, "handler") })
+
+	req := httptest.NewRequest("GET", "/hello", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	want := []string{"first", "second", "handler"}
+	if len(order) != len(want) {
+		t.Fatalf("expected call order %v, got %v", want, order)
+	}
+	for i, name := range want {
+		if order[i] != name {
+			t.Fatalf("expected call order %v, got %v", want, order)
+		}
+	}
+}
+
+func TestUseOnlyAffectsRoutesRegisteredAfterward(t *testing.T) {
+	var ran bool
+
+	router := NewRouter()
+	router.GET("/before", func(d *Data) {})
+	router.Use(func(next Callback) Callback {
+		return func(d *Data) {
+			ran = true
+			next(d)
+		}
+	})
+
+	req := httptest.NewRequest("GET", "/before", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if ran {
+		t.Fatal("expected middleware added after registration to not run for /before")
+	}
+}
+
+func TestWithLayersMiddlewareOntoChildRouterOnly(t *testing.T) {
+	var parentRan, childRan bool
+
+	router := NewRouter()
+	router.Use(func(next Callback) Callback {
+		return func(d *Data) {
+			parentRan = true
+			next(d)
+		}
+	})
+
+	child := router.With(func(next Callback) Callback {
+		return func(d *Data) {
+			childRan = true
+			next(d)
+		}
+	})
+	child.GET("/child", func(d *Data) {})
+	router.GET("/parent", func(d *Data) {})
+
+	req := httptest.NewRequest("GET", "/parent", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if !parentRan || childRan {
+		t.Fatalf("expected only the parent's middleware to run for /parent, got parentRan=%v childRan=%v", parentRan, childRan)
+	}
+
+	parentRan, childRan = false, false
+	req = httptest.NewRequest("GET", "/child", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if !parentRan || !childRan {
+		t.Fatalf("expected /child to run both inherited and child middleware, got parentRan=%v childRan=%v", parentRan, childRan)
+	}
+}
+
+func TestGroupPrefixesRoutesAndNests(t *testing.T) {
+	router := NewRouter()
+	router.Group("/api", func(api *Router) {
+		api.GET("/users", func(d *Data) { d.Write("users") })
+
+		api.Group("/v2", func(v2 *Router) {
+			v2.GET("/users", func(d *Data) { d.Write("v2 users") })
+		})
+	})
+
+	req := httptest.NewRequest("GET", "/api/users", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Body.String() != "users" {
+		t.Fatalf("expected /api/users to be routed, got %q", w.Body.String())
+	}
+
+	req = httptest.NewRequest("GET", "/api/v2/users", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Body.String() != "v2 users" {
+		t.Fatalf("expected nested group prefix /api/v2/users to be routed, got %q", w.Body.String())
+	}
+
+	// the parent router itself must stay unprefixed and unaffected by Group
+	req = httptest.NewRequest("GET", "/users", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code == 200 {
+		t.Fatalf("expected /users (without the group prefix) to not be routed on the parent router")
+	}
+}
+
+func TestMiddlewareWrappedOnceAtRegistration(t *testing.T) {
+	var wraps int
+
+	router := NewRouter()
+	router.Use(func(next Callback) Callback {
+		wraps++
+		return next
+	})
+	router.GET("/hello", func(d *Data) {})
+
+	if wraps != 1 {
+		t.Fatalf("expected middleware to wrap the handler once at registration, got %d wraps", wraps)
+	}
+
+	for i := 0; i < 3; i++ {
+		req := httptest.NewRequest("GET", "/hello", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+	}
+
+	if wraps != 1 {
+		t.Fatalf("expected no additional wraps from serving requests, got %d wraps", wraps)
+	}
+}
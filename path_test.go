@@ -0,0 +1,74 @@
+package tube
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCleanPath(t *testing.T) {
+	cases := map[string]string{
+		"":                 "/",
+		"/":                "/",
+		"//foo//bar":       "/foo/bar",
+		"/foo/./bar":       "/foo/bar",
+		"/foo/../bar":      "/bar",
+		"/../../etc":       "/etc",
+		"assets/style.css": "/assets/style.css",
+	}
+	for in, want := range cases {
+		if got := CleanPath(in); got != want {
+			t.Errorf("CleanPath(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestServeHTTPEscapedTraversalBlocked(t *testing.T) {
+	router := NewRouter()
+	router.GET("/download/@@file", func(d *Data) {
+		d.Write("served:" + d.P("file"))
+	})
+
+	// %2e%2e%2f decodes to "../" but must not be allowed to escape the
+	// "/download" prefix during route matching
+	req := httptest.NewRequest("GET", "/download/%2e%2e%2fsecret", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Body.String() != "served:../secret" {
+		t.Fatalf("expected the literal decoded segment to stay under /download, got %q", w.Body.String())
+	}
+}
+
+func TestRedirectFixedPath(t *testing.T) {
+	router := NewRouter()
+	router.SetRedirectFixedPath(true)
+	router.GET("/foo", func(d *Data) {})
+
+	req := httptest.NewRequest("GET", "//foo", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != 301 {
+		t.Fatalf("expected 301 redirect to canonical path, got %d", w.Code)
+	}
+	if loc := w.Header().Get("Location"); loc != "/foo" {
+		t.Fatalf("expected redirect to /foo, got %q", loc)
+	}
+}
+
+func TestRedirectFixedPathPreservesPercentEscapes(t *testing.T) {
+	router := NewRouter()
+	router.SetRedirectFixedPath(true)
+	router.GET("/foo bar", func(d *Data) {})
+
+	req := httptest.NewRequest("GET", "//foo%20bar", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != 301 {
+		t.Fatalf("expected 301 redirect to canonical path, got %d", w.Code)
+	}
+	if loc := w.Header().Get("Location"); loc != "/foo%20bar" {
+		t.Fatalf("expected redirect to /foo%%20bar without double-encoding, got %q", loc)
+	}
+}
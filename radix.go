@@ -0,0 +1,179 @@
+package tube
+
+import "strings"
+
+// a node in the per-method route tree. Static children are indexed by
+// their first byte for O(1) descent; each node additionally has at most
+// one param child (matches a single @name path segment) and one wildcard
+// child (matches a trailing @@name to the end of the path)
+type node struct {
+	prefix       string
+	static       map[byte]*node
+	param        *node
+	paramName    string
+	wildcard     *node
+	wildcardName string
+	route        *route
+}
+
+// a parsed fragment of a route pattern: either a literal run of
+// characters, a single-segment param (@name), or a trailing wildcard (@@name)
+type patternToken struct {
+	literal  string
+	name     string
+	wildcard bool
+}
+
+// splits a pattern into literal/param/wildcard tokens, preserving the
+// @name/@@name syntax used by parsePattern
+func tokenizePattern(pattern string) []patternToken {
+	var tokens []patternToken
+
+	i := 0
+	for i < len(pattern) {
+		at := strings.IndexByte(pattern[i:], '@')
+		if at < 0 {
+			tokens = append(tokens, patternToken{literal: pattern[i:]})
+			break
+		}
+		at += i
+		if at > i {
+			tokens = append(tokens, patternToken{literal: pattern[i:at]})
+		}
+
+		if strings.HasPrefix(pattern[at:], "@@") {
+			// wildcard always runs to the end of the pattern
+			tokens = append(tokens, patternToken{name: pattern[at+2:], wildcard: true})
+			break
+		}
+
+		rest := pattern[at+1:]
+		if end := strings.IndexByte(rest, '/'); end >= 0 {
+			tokens = append(tokens, patternToken{name: rest[:end]})
+			i = at + 1 + end
+		} else {
+			tokens = append(tokens, patternToken{name: rest})
+			break
+		}
+	}
+
+	return tokens
+}
+
+// inserts rt into the tree rooted at root for pattern, splitting and
+// branching nodes as needed
+func insertPattern(root *node, pattern string, rt *route) {
+	n := root
+	for _, tok := range tokenizePattern(pattern) {
+		switch {
+		case tok.wildcard:
+			n = n.insertWildcard(tok.name)
+		case tok.name != "":
+			n = n.insertParam(tok.name)
+		default:
+			n = n.insertLiteral(tok.literal)
+		}
+	}
+	n.route = rt
+}
+
+func commonPrefixLen(a, b string) int {
+	max := len(a)
+	if len(b) < max {
+		max = len(b)
+	}
+	i := 0
+	for i < max && a[i] == b[i] {
+		i++
+	}
+	return i
+}
+
+func (n *node) insertLiteral(lit string) *node {
+	if lit == "" {
+		return n
+	}
+
+	if child, ok := n.static[lit[0]]; ok {
+		common := commonPrefixLen(child.prefix, lit)
+		if common < len(child.prefix) {
+			// the new pattern only shares part of this edge: split it,
+			// promoting the shared prefix and demoting the divergent tail
+			tail := &node{
+				prefix:       child.prefix[common:],
+				static:       child.static,
+				param:        child.param,
+				paramName:    child.paramName,
+				wildcard:     child.wildcard,
+				wildcardName: child.wildcardName,
+				route:        child.route,
+			}
+			child.prefix = child.prefix[:common]
+			child.static = map[byte]*node{tail.prefix[0]: tail}
+			child.param, child.paramName = nil, ""
+			child.wildcard, child.wildcardName = nil, ""
+			child.route = nil
+		}
+		return child.insertLiteral(lit[common:])
+	}
+
+	nn := &node{prefix: lit}
+	if n.static == nil {
+		n.static = map[byte]*node{}
+	}
+	n.static[lit[0]] = nn
+	return nn
+}
+
+func (n *node) insertParam(name string) *node {
+	if n.param == nil {
+		n.param = &node{}
+		n.paramName = name
+	}
+	return n.param
+}
+
+func (n *node) insertWildcard(name string) *node {
+	if n.wildcard == nil {
+		n.wildcard = &node{}
+		n.wildcardName = name
+	}
+	return n.wildcard
+}
+
+// walks the tree byte-by-byte, preferring the static branch and
+// backtracking to param/wildcard branches only once it dead-ends,
+// collecting captured segments along the way
+func (n *node) match(path string) (*route, Params, bool) {
+	if path == "" {
+		if n.route != nil {
+			return n.route, Params{}, true
+		}
+		return nil, nil, false
+	}
+
+	if child, ok := n.static[path[0]]; ok && strings.HasPrefix(path, child.prefix) {
+		if rt, params, ok := child.match(path[len(child.prefix):]); ok {
+			return rt, params, true
+		}
+	}
+
+	if n.param != nil {
+		seg, rest := path, ""
+		if i := strings.IndexByte(path, '/'); i >= 0 {
+			seg, rest = path[:i], path[i:]
+		}
+		if seg != "" {
+			if rt, params, ok := n.param.match(rest); ok {
+				params[n.paramName] = seg
+				return rt, params, true
+			}
+		}
+	}
+
+	if n.wildcard != nil && n.wildcard.route != nil {
+		return n.wildcard.route, Params{n.wildcardName: path}, true
+	}
+
+	return nil, nil, false
+}
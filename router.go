@@ -4,13 +4,16 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"io/fs"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
 	"path"
 	"regexp"
 	"strings"
 	"sync"
+	"time"
 )
 
 // map of url params by @name
@@ -49,18 +52,18 @@ func (d *Data) Redirect(url string, code int) {
 
 // sends and caches '404 not found'
 func (d *Data) NotFound() {
-	url := path.Clean(d.Request.URL.Path)
+	url := CleanPath(d.Request.URL.Path)
 	cacheName := d.Request.Method + " " + url
-	d.router.writeCache(cacheName, d.router.route404)
-	d.router.callRoute(d.router.route404, url, d.Writer, d.Request)
+	d.router.writeCache(cacheName, d.router.core.route404, nil)
+	d.router.callRoute(d.router.core.route404, nil, url, d.Writer, d.Request)
 }
 
 // sends '500 internal server error'
 func (d *Data) Error(err error) {
-	d.router.logger.Println("internal server error:", err)
+	d.router.core.logger.Println("internal server error:", err)
 
-	url := path.Clean(d.Request.URL.Path)
-	d.router.callRoute(d.router.route500, url, d.Writer, d.Request)
+	url := CleanPath(d.Request.URL.Path)
+	d.router.callRoute(d.router.core.route500, nil, url, d.Writer, d.Request)
 }
 
 // get json request data
@@ -71,16 +74,16 @@ func (d *Data) Json(v interface{}) error {
 	err := obj.Decode(v)
 	if err != nil {
 		d.Status(http.StatusBadRequest)
-		if d.router.logLevel >= LOG_DEBUG {
-			d.router.logger.Printf("invalid request %s", err)
+		if d.router.core.logLevel >= LOG_DEBUG {
+			d.router.core.logger.Printf("invalid request %s", err)
 		}
 		return err
 	}
 
 	if obj.More() {
 		d.Status(http.StatusBadRequest)
-		if d.router.logLevel >= LOG_DEBUG {
-			d.router.logger.Println("extra data in request")
+		if d.router.core.logLevel >= LOG_DEBUG {
+			d.router.core.logger.Println("extra data in request")
 		}
 		return err
 	}
@@ -102,10 +105,19 @@ func (d *Data) WriteJson(v interface{}) error {
 type Callback func(*Data)
 
 type route struct {
-	pattern  *regexp.Regexp
 	callback Callback
-	params   []string
 	method   string
+	// the pattern(s) this route was registered under, post prefix/trim;
+	// StaticDir routes created via Dir() register two (the bare dir, and
+	// the dir plus a wildcard path) against this single route
+	patterns []string
+}
+
+// a route resolved from the tree together with the params captured for it,
+// cached verbatim so a cache hit never has to re-walk the tree
+type cachedRoute struct {
+	route  *route
+	params Params
 }
 
 const (
@@ -114,26 +126,109 @@ const (
 	LOG_DEBUG
 )
 
+// state shared by a Router and every Router derived from it via With()/Group()
+type routerCore struct {
+	routes            []*route         // registered routes, for RemoveRoute/rebuildTrees
+	lateRoutes        []*route         // registered LateRoute routes
+	trees             map[string]*node // primary route tree, keyed by method
+	lateTrees         map[string]*node // LateRoute tree, consulted after trees misses
+	route404          *route
+	route500          *route
+	routeCache        map[string]*cachedRoute
+	routeMutex        sync.RWMutex
+	htmlCache         map[string]string
+	htmlDeps          map[string][]string // cached URL -> fsys-relative files its render depended on, see WatchStatic
+	htmlMutex         sync.RWMutex
+	noCache           bool
+	htmlDisabled      bool
+	redirectFixedPath bool  // 301-redirect non-canonical request paths to their CleanPath() form
+	defaultFS         fs.FS // root used to resolve includes in handler-supplied HTML
+	logger            *log.Logger
+	logLevel          int
+	requestLog        RequestLog       // receives a RequestLogEntry after every request
+	ignoreLogs        []*regexp.Regexp // patterns excluded from request logging, see IgnoreLogs
+}
+
 type Router struct {
-	routes       []*route
-	lateRoutes   []*route
-	route404     *route
-	route500     *route
-	routeCache   map[string]*route
-	routeMutex   sync.RWMutex
-	htmlCache    map[string]string
-	htmlMutex    sync.RWMutex
-	noCache      bool
-	htmlDisabled bool
-	logger       *log.Logger
-	logLevel     int
+	core       *routerCore
+	middleware []func(Callback) Callback
+	prefix     string
 }
 
-func parsePattern(str string) (string, []string) {
-	// ensure all routes both begin with a '/' and end without one
+// wraps cb with mw, applied in registration order so the first middleware
+// passed to Use()/With() ends up outermost (runs first)
+func wrapMiddleware(cb Callback, mw []func(Callback) Callback) Callback {
+	for i := len(mw) - 1; i >= 0; i-- {
+		cb = mw[i](cb)
+	}
+	return cb
+}
+
+// appends middleware applied to every route registered on this router from
+// this point on (routes already registered are unaffected)
+func (router *Router) Use(mw ...func(Callback) Callback) {
+	router.middleware = append(router.middleware, mw...)
+}
+
+// returns a child Router sharing this router's routes/caches but layering
+// extra middleware on top of it for any routes registered through the child
+func (router *Router) With(mw ...func(Callback) Callback) *Router {
+	child := &Router{
+		core:   router.core,
+		prefix: router.prefix,
+	}
+	child.middleware = append(append([]func(Callback) Callback{}, router.middleware...), mw...)
+	return child
+}
+
+// mounts a subtree of routes under prefix by calling fn with a child router
+// (see With()) whose registrations are automatically prefixed
+func (router *Router) Group(prefix string, fn func(*Router)) {
+	child := router.With()
+	child.prefix = router.prefix + prefix
+	fn(child)
+}
+
+// CleanPath collapses duplicate slashes and resolves '.'/'..' segments
+// purely lexically (no filesystem access), returning a canonical,
+// slash-prefixed path. Used to canonicalize a request's raw, still-escaped
+// path before route matching, so a percent-encoded '..' can't be decoded
+// into a real '..' only after it has already slipped past a route's
+// declared prefix.
+func CleanPath(str string) string {
+	if str == "" {
+		return "/"
+	}
+	if str[0] != '/' {
+		str = "/" + str
+	}
+	return path.Clean(str)
+}
+
+// trims the trailing '/' from all routes except the root, so every
+// registered pattern both begins with a '/' and ends without one
+func trimTrailingSlash(str string) string {
 	if str != "/" && strings.HasSuffix(str, "/") {
 		str = str[:len(str)-1]
 	}
+	return str
+}
+
+// returns the path portion of r's original request target (RequestURI),
+// unaffected by CleanPath's normalization of r.URL.Path; used where a
+// trailing slash on the client's actual request matters
+func requestURIPath(r *http.Request) string {
+	uri := r.RequestURI
+	if i := strings.IndexByte(uri, '?'); i >= 0 {
+		uri = uri[:i]
+	}
+	return uri
+}
+
+// used only to build a regexp for matching cache keys in ClearCache; actual
+// route dispatch is done by the tree built with insertPattern
+func parsePattern(str string) (string, []string) {
+	str = trimTrailingSlash(str)
 
 	var params []string
 	if strings.Contains(str, "@") {
@@ -152,40 +247,75 @@ func parsePattern(str string) (string, []string) {
 	return str, params
 }
 
+// dirSuffix marks a pattern produced by Dir(): it is expanded into two
+// separate tree insertions so the trailing '/' + path stays optional
+// without needing a regex-style optional group in the tree
+const dirSuffix = "\x00dir"
+
+// expands a Dir()-tagged pattern into the one or two patterns it should be
+// inserted into the tree under
+func expandDirPattern(str string) []string {
+	if base, ok := strings.CutSuffix(str, dirSuffix); ok {
+		return []string{base, path.Join(base, "@@path")}
+	}
+	return []string{str}
+}
+
+func insertIntoTrees(trees map[string]*node, method, pattern string, rt *route) {
+	root, ok := trees[method]
+	if !ok {
+		root = &node{}
+		trees[method] = root
+	}
+	insertPattern(root, pattern, rt)
+}
+
+// rebuilds the route trees from scratch from router.core.routes; used after
+// RemoveRoute since deleting a single pattern out of a shared tree in place
+// would require re-merging split edges
+func (core *routerCore) rebuildTrees() {
+	core.trees = map[string]*node{}
+	for _, rt := range core.routes {
+		for _, p := range rt.patterns {
+			insertIntoTrees(core.trees, rt.method, p, rt)
+		}
+	}
+}
+
 func (router *Router) createRoute(str string, cb Callback, mthd string, late bool) {
-	if router.logLevel >= LOG_DEBUG {
-		router.logger.Println("creating route", mthd, str)
+	str = router.prefix + str
+
+	if router.core.logLevel >= LOG_DEBUG {
+		router.core.logger.Println("creating route", mthd, str)
 	}
 
-	rt := &route{}
-	rt.method = mthd
+	rt := &route{method: mthd, callback: wrapMiddleware(cb, router.middleware)}
 
-	str, rt.params = parsePattern(str)
-	rt.pattern = regexp.MustCompile(str)
-	rt.callback = cb
+	trees := router.core.trees
+	if late {
+		trees = router.core.lateTrees
+	}
+
+	for _, p := range expandDirPattern(str) {
+		p = trimTrailingSlash(p)
+		rt.patterns = append(rt.patterns, p)
+		insertIntoTrees(trees, mthd, p, rt)
+	}
 
 	if late {
-		router.lateRoutes = append(router.lateRoutes, rt)
+		router.core.lateRoutes = append(router.core.lateRoutes, rt)
 	} else {
-		router.routes = append(router.routes, rt)
+		router.core.routes = append(router.core.routes, rt)
 	}
 }
 
 // returns a pattern to match a whole dir (for use with StaticDir)
 func (router *Router) Dir(str string) string {
-	// do this first so we can put path param after user-defined pattern
-	// ensure all routes both begin with a '/' and end without one
-	if str != "/" && strings.HasSuffix(str, "/") {
-		str = str[:len(str)-1]
-	}
-
-	// optional '/' allows both '/assets/test.txt' and '/assets' to match,
-	// as most browsers will remove the trailing '/' for urls with no file.
-	// this optional '/' is kept out of the parm param for consistency, as it
-	// will be added infront of the param for all paths in StaticDir
-	str = str + "(?:/?@@path)?"
-
-	return str
+	// optional '/' + path allows both '/assets/test.txt' and '/assets' to
+	// match, as most browsers will remove the trailing '/' for urls with no
+	// file. createRoute expands this into two tree insertions: the bare
+	// dir, and the dir followed by a '@@path' wildcard
+	return trimTrailingSlash(str) + dirSuffix
 }
 
 func (router *Router) Route(method string, str string, cb Callback) {
@@ -220,30 +350,69 @@ func (router *Router) PATCH(str string, cb Callback) {
 	router.createRoute(str, cb, "PATCH", false)
 }
 
-// returns a Callback function for serving static files from a directory
-func (router *Router) StaticDir(dir string) func(*Data) {
-	fileServer := http.FileServer(staticFs{http.Dir(dir)})
+// builds the Callback shared by StaticDir and StaticFS: serves the
+// directory tree at the root of fsys, resolving index.html and running the
+// HTML include parser against fsys as well. When opts.Browse is set, a
+// directory lacking index.html (or with opts.IgnoreIndexes set) renders a
+// listing instead of 404ing
+func (router *Router) staticDirHandler(fsys fs.FS, opts BrowseOptions) Callback {
+	fileServer := http.FileServer(staticFs{http.FS(fsys)})
 
 	return func(d *Data) {
+		externalPath := d.Request.URL.Path
+
 		// overwrite url with relative param path
 		_, hasPath := d.Params["path"]
 		if hasPath {
 			d.Request.URL.Path = "/" + d.Params["path"]
 		}
 
+		reqPath := strings.TrimPrefix(d.Request.URL.Path, "/")
+		if reqPath == "" {
+			reqPath = "."
+		}
+
+		hasIndex := false
+		if path.Ext(d.Request.URL.Path) == "" {
+			if _, err := fs.Stat(fsys, path.Join(reqPath, "index.html")); err == nil {
+				hasIndex = true
+			}
+		}
+
+		if opts.Browse && path.Ext(d.Request.URL.Path) == "" && (!hasIndex || opts.IgnoreIndexes) {
+			if info, err := fs.Stat(fsys, reqPath); err == nil && info.IsDir() {
+				// the listing's relative links only resolve correctly
+				// against a URL ending in '/' (RFC 3986 relative
+				// resolution), so canonicalize bare directory requests
+				// before rendering, same as Apache/nginx/caddy. Checked
+				// against the raw request target, since CleanPath has
+				// already stripped any trailing slash from
+				// d.Request.URL.Path by the time the handler runs
+				if !strings.HasSuffix(requestURIPath(d.Request), "/") {
+					target := externalPath + "/"
+					if d.Request.URL.RawQuery != "" {
+						target += "?" + d.Request.URL.RawQuery
+					}
+					d.Redirect(target, http.StatusMovedPermanently)
+					return
+				}
+				router.serveBrowseListing(d, fsys, reqPath, opts)
+				return
+			}
+		}
+
 		// determine index.html path for html parser
 		// if no file extension, use path/index.html if exists
-		if !router.htmlDisabled && path.Ext(d.Request.URL.Path) == "" {
-			if _, err := os.Stat(path.Join(dir, d.Request.URL.Path, "index.html")); err == nil {
-				d.Request.URL.Path = path.Join(d.Request.URL.Path, "index.html")
-			}
+		if !router.core.htmlDisabled && hasIndex {
+			d.Request.URL.Path = path.Join(d.Request.URL.Path, "index.html")
+			reqPath = path.Join(reqPath, "index.html")
 		}
 
 		// use html parser if enabled
-		if !router.htmlDisabled && strings.HasSuffix(d.Request.URL.Path, ".html") {
-			router.serveHTMLStatic(d, dir, d.Request.URL.Path)
+		if !router.core.htmlDisabled && strings.HasSuffix(d.Request.URL.Path, ".html") {
+			router.serveHTMLStatic(d, fsys, ".", d.Request.URL.Path)
 		} else {
-			if _, err := os.Stat(path.Join(dir, d.Request.URL.Path)); err != nil {
+			if _, err := fs.Stat(fsys, reqPath); err != nil {
 				d.NotFound()
 				return
 			}
@@ -252,109 +421,207 @@ func (router *Router) StaticDir(dir string) func(*Data) {
 	}
 }
 
-// returns a Callback function for serving a single static file
-func (router *Router) StaticFile(fn string) func(*Data) {
-	basefn := path.Base(fn)
-	dir := path.Dir(fn)
+// builds the Callback shared by StaticFile and StaticFileFS: serves name
+// out of fsys, running the HTML include parser against fsys when name ends
+// in .html
+func (router *Router) staticFileHandler(name string, fsys fs.FS) Callback {
+	dir, base := path.Dir(name), path.Base(name)
+	fileServer := http.FileServer(http.FS(fsys))
 
 	return func(d *Data) {
-		if !router.htmlDisabled && strings.HasSuffix(fn, ".html") {
-			d.Request.URL.Path = basefn
-			router.serveHTMLStatic(d, dir, d.Request.URL.Path)
+		d.Request.URL.Path = "/" + name
+
+		if !router.core.htmlDisabled && strings.HasSuffix(name, ".html") {
+			router.serveHTMLStatic(d, fsys, dir, base)
 		} else {
-			if _, err := os.Stat(fn); err != nil {
+			if _, err := fs.Stat(fsys, name); err != nil {
 				d.Error(fmt.Errorf("static file mapped to nonexistent file"))
 				return
 			}
-			http.ServeFile(d.Writer, d.Request, fn)
+			fileServer.ServeHTTP(d.Writer, d.Request)
 		}
 	}
 }
 
+// returns a Callback function for serving static files from an OS directory.
+// opts is optional and defaults to BrowseOptions{} (listings disabled); pass
+// BrowseOptions{Browse: true} to enable directory listings, or use
+// BrowsableDir for the route-registering equivalent
+func (router *Router) StaticDir(dir string, opts ...BrowseOptions) func(*Data) {
+	return router.staticDirHandler(os.DirFS(dir), firstBrowseOptions(opts))
+}
+
+// returns a Callback function for serving a single static file from the OS
+func (router *Router) StaticFile(fn string) func(*Data) {
+	return router.staticFileHandler(path.Base(fn), os.DirFS(path.Dir(fn)))
+}
+
+// registers a GET route serving the directory tree at the root of fsys,
+// e.g. an embed.FS or a zip archive opened with zip.Reader.Open
+func (router *Router) StaticFS(pattern string, fsys fs.FS, opts ...BrowseOptions) {
+	router.GET(router.Dir(pattern), router.staticDirHandler(fsys, firstBrowseOptions(opts)))
+}
+
+// registers a GET route serving dir with directory listings enabled for any
+// directory lacking an index.html (or all directories, if opts.IgnoreIndexes
+// is set), in the spirit of caddy's browse middleware
+func (router *Router) BrowsableDir(pattern, dir string, opts BrowseOptions) Callback {
+	opts.Browse = true
+	cb := router.staticDirHandler(os.DirFS(dir), opts)
+	router.GET(router.Dir(pattern), cb)
+	return cb
+}
+
+func firstBrowseOptions(opts []BrowseOptions) BrowseOptions {
+	if len(opts) > 0 {
+		return opts[0]
+	}
+	return BrowseOptions{}
+}
+
+// registers a GET route serving a single file out of fsys
+func (router *Router) StaticFileFS(pattern, name string, fsys fs.FS) {
+	router.GET(pattern, router.staticFileHandler(name, fsys))
+}
+
 // set 404 route
 func (router *Router) Set404(cb Callback) {
 	rt := &route{}
 	rt.callback = cb
-	router.route404 = rt
+	router.core.route404 = rt
 }
 
 // set 500 route
 func (router *Router) Set500(cb Callback) {
 	rt := &route{}
 	rt.callback = cb
-	router.route500 = rt
+	router.core.route500 = rt
 }
 
-func (router *Router) callRoute(rt *route, url string, w http.ResponseWriter, r *http.Request) {
-	p := Params{}
-	if len(rt.params) > 0 {
-		matches := rt.pattern.FindAllStringSubmatch(url, -1)
-		params := matches[0][1:]
-		for num, val := range params {
-			p[rt.params[num]] = val
-		}
+func (router *Router) callRoute(rt *route, params Params, url string, w http.ResponseWriter, r *http.Request) {
+	if params == nil {
+		params = Params{}
 	}
 
-	data := &Data{router, w, r, p, http.StatusOK, ""}
-	if rt == router.route404 {
+	accepted := time.Now()
+	logWriter := newResponseLogWriter(w)
+
+	data := &Data{router, logWriter, r, params, http.StatusOK, ""}
+	if rt == router.core.route404 {
 		data.Status(http.StatusNotFound)
-	} else if rt == router.route500 {
+	} else if rt == router.core.route500 {
 		data.Status(http.StatusInternalServerError)
 	}
+
+	routed := time.Now()
 	rt.callback(data)
 
 	if data.HTML != "" {
-		router.serveHTML(data, false, data.HTML, "/")
+		router.serveHTML(data, router.core.defaultFS, false, data.HTML, "/", nil)
 	}
 
-	if router.logLevel >= LOG_INFO || data.status == http.StatusInternalServerError {
-		router.logger.Println(r.Method, url, data.status)
+	if router.core.requestLog != nil && !router.shouldIgnoreLog(r) {
+		status := data.status
+		headerComplete := routed
+		if logWriter.wroteHeader {
+			status = logWriter.status
+			headerComplete = logWriter.headerAt
+		}
+
+		router.core.requestLog.Log(RequestLogEntry{
+			Request:        r,
+			Status:         status,
+			Size:           logWriter.size,
+			Accepted:       accepted,
+			Routed:         routed,
+			HeaderComplete: headerComplete,
+			Flushed:        time.Now(),
+		})
 	}
 }
 
-func (router *Router) writeCache(cacheName string, rt *route) {
-	router.routeMutex.Lock()
-	router.routeCache[cacheName] = rt
-	router.routeMutex.Unlock()
+func (router *Router) writeCache(cacheName string, rt *route, params Params) {
+	router.core.routeMutex.Lock()
+	router.core.routeCache[cacheName] = &cachedRoute{rt, params}
+	router.core.routeMutex.Unlock()
+}
+
+func (router *Router) matchMethod(trees map[string]*node, method, path string) (*route, Params, bool) {
+	root, ok := trees[method]
+	if !ok {
+		return nil, nil, false
+	}
+	return root.match(path)
+}
+
+// unescapes every captured param value in place, failing if any value
+// contains malformed percent-encoding
+func unescapeParams(params Params) (Params, bool) {
+	for name, val := range params {
+		decoded, err := url.PathUnescape(val)
+		if err != nil {
+			return nil, false
+		}
+		params[name] = decoded
+	}
+	return params, true
 }
 
 func (router *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	url := path.Clean(r.URL.Path)
-	r.URL.Path = url
+	// match against the still-escaped path so a percent-encoded '..' or '/'
+	// can't be decoded into a real path separator before route matching
+	// sees it; params captured off of it are unescaped afterwards, once
+	// they can no longer influence which route matched
+	escaped := CleanPath(r.URL.EscapedPath())
+
+	reqPath, err := url.PathUnescape(escaped)
+	if err != nil {
+		router.callRoute(router.core.route404, nil, escaped, w, r)
+		return
+	}
+
+	if router.core.redirectFixedPath && escaped != r.URL.EscapedPath() {
+		redirectURL := *r.URL
+		redirectURL.Path = reqPath
+		redirectURL.RawPath = escaped
+		http.Redirect(w, r, redirectURL.String(), http.StatusMovedPermanently)
+		return
+	}
+
+	r.URL.Path = reqPath
 
 	method := r.Method
-	cacheName := method + " " + url
+	cacheName := method + " " + escaped
 
 	// use cached route
-	router.routeMutex.RLock()
-	route, cached := router.routeCache[cacheName]
-	router.routeMutex.RUnlock()
-	if cached && !router.noCache {
-		router.callRoute(route, url, w, r)
+	router.core.routeMutex.RLock()
+	cached, isCached := router.core.routeCache[cacheName]
+	router.core.routeMutex.RUnlock()
+	if isCached && !router.core.noCache {
+		router.callRoute(cached.route, cached.params, reqPath, w, r)
 		return
 	}
 
-	// use first route that matches request URL
-	for _, rt := range router.routes {
-		if rt.method == method && rt.pattern.MatchString(url) {
-			router.writeCache(cacheName, rt)
-			router.callRoute(rt, url, w, r)
+	// use the primary tree, falling back to the late tree, for this method
+	if rt, params, ok := router.matchMethod(router.core.trees, method, escaped); ok {
+		if params, ok = unescapeParams(params); ok {
+			router.writeCache(cacheName, rt, params)
+			router.callRoute(rt, params, reqPath, w, r)
 			return
 		}
 	}
 
-	// check late routes last
-	for _, rt := range router.lateRoutes {
-		if rt.method == method && rt.pattern.MatchString(url) {
-			router.writeCache(cacheName, rt)
-			router.callRoute(rt, url, w, r)
+	if rt, params, ok := router.matchMethod(router.core.lateTrees, method, escaped); ok {
+		if params, ok = unescapeParams(params); ok {
+			router.writeCache(cacheName, rt, params)
+			router.callRoute(rt, params, reqPath, w, r)
 			return
 		}
 	}
 
 	// not found
-	router.writeCache(cacheName, router.route404)
-	router.callRoute(router.route404, url, w, r)
+	router.writeCache(cacheName, router.core.route404, nil)
+	router.callRoute(router.core.route404, nil, reqPath, w, r)
 }
 
 // clear cache that matches a pattern
@@ -362,82 +629,148 @@ func (router *Router) ClearCache(str string) {
 	str = "[A-Z]+ " + str // METHOD url/foo/bar
 	rx, _ := parsePattern(str)
 	pattern := regexp.MustCompile(rx)
-	router.routeMutex.Lock()
-	for url := range router.routeCache {
+	router.core.routeMutex.Lock()
+	for url := range router.core.routeCache {
 		if pattern.MatchString(url) {
-			delete(router.routeCache, url)
+			delete(router.core.routeCache, url)
 		}
 	}
-	router.routeMutex.Unlock()
+	router.core.routeMutex.Unlock()
 
-	router.htmlMutex.Lock()
-	for url := range router.htmlCache {
+	router.core.htmlMutex.Lock()
+	for url := range router.core.htmlCache {
 		if pattern.MatchString(url) {
-			delete(router.htmlCache, url)
+			delete(router.core.htmlCache, url)
+			delete(router.core.htmlDeps, url)
 		}
 	}
-	router.htmlMutex.Unlock()
+	router.core.htmlMutex.Unlock()
 }
 
 // empty cache completely
 func (router *Router) EmptyCache() {
-	router.routeMutex.Lock()
-	router.htmlMutex.Lock()
-	clear(router.routeCache)
-	clear(router.htmlCache)
-	router.htmlMutex.Unlock()
-	router.routeMutex.Unlock()
+	router.core.routeMutex.Lock()
+	router.core.htmlMutex.Lock()
+	clear(router.core.routeCache)
+	clear(router.core.htmlCache)
+	clear(router.core.htmlDeps)
+	router.core.htmlMutex.Unlock()
+	router.core.routeMutex.Unlock()
 }
 
-// removes routes that patch a pattern
+// removes routes registered under the exact pattern str, rebuilding the
+// route tree to match
 func (router *Router) RemoveRoute(str string) {
-	for i, rt := range router.routes {
-		if rt.pattern.MatchString(str) {
-			router.routes = append(router.routes[:i], router.routes[i+1:]...)
-			router.ClearCache(str)
+	str = trimTrailingSlash(router.prefix + str)
+
+	removed := false
+	routes := router.core.routes[:0]
+	for _, rt := range router.core.routes {
+		match := false
+		for _, p := range rt.patterns {
+			if p == str {
+				match = true
+				break
+			}
 		}
+		if match {
+			removed = true
+			continue
+		}
+		routes = append(routes, rt)
+	}
+	router.core.routes = routes
+
+	if removed {
+		router.core.rebuildTrees()
+		router.ClearCache(str)
 	}
 }
 
 // set log level
 func (router *Router) SetLogLevel(i int) {
-	router.logLevel = i
+	router.core.logLevel = i
 }
 
 // set logger
 func (router *Router) SetLogger(logger *log.Logger) {
-	router.logger = logger
+	router.core.logger = logger
+}
+
+// swaps in a custom RequestLog, e.g. to feed request entries into a
+// structured logging pipeline instead of the default router.logger summary
+func (router *Router) SetRequestLog(rl RequestLog) {
+	router.core.requestLog = rl
+}
+
+// excludes requests whose Host+RequestURI match any of the given doublestar
+// globs (e.g. "**/healthz" or "**/assets/**") from request logging; invalid
+// patterns are logged and otherwise ignored. Replaces any previously set
+// patterns
+func (router *Router) IgnoreLogs(patterns []string) {
+	ignore := make([]*regexp.Regexp, 0, len(patterns))
+	for _, pattern := range patterns {
+		re, err := doublestarToRegexp(pattern)
+		if err != nil {
+			router.core.logger.Printf("IgnoreLogs: skipping invalid pattern %q: %s", pattern, err)
+			continue
+		}
+		ignore = append(ignore, re)
+	}
+	router.core.ignoreLogs = ignore
+}
+
+// reports whether r should be excluded from request logging per IgnoreLogs
+func (router *Router) shouldIgnoreLog(r *http.Request) bool {
+	subject := r.Host + r.RequestURI
+	for _, re := range router.core.ignoreLogs {
+		if re.MatchString(subject) {
+			return true
+		}
+	}
+	return false
+}
+
+// when enabled, requests whose raw path isn't already in CleanPath() form
+// get a 301 redirect to the canonical path instead of being matched as-is
+func (router *Router) SetRedirectFixedPath(enabled bool) {
+	router.core.redirectFixedPath = enabled
 }
 
 // create and initialize new router
 func NewRouter() *Router {
-	router := &Router{}
-	router.routeCache = map[string]*route{}
-	router.htmlCache = map[string]string{}
+	router := &Router{core: &routerCore{}}
+	router.core.trees = map[string]*node{}
+	router.core.lateTrees = map[string]*node{}
+	router.core.routeCache = map[string]*cachedRoute{}
+	router.core.htmlCache = map[string]string{}
+	router.core.htmlDeps = map[string][]string{}
+	router.core.defaultFS = os.DirFS(".")
 
 	// default error routes
 	rt404 := &route{}
 	rt404.callback = func(d *Data) {
 		io.WriteString(d.Writer, "404 file not found")
 	}
-	router.route404 = rt404
+	router.core.route404 = rt404
 
 	rt500 := &route{}
 	rt500.callback = func(d *Data) {
 		io.WriteString(d.Writer, "500 internal server error")
 	}
-	router.route500 = rt500
+	router.core.route500 = rt500
 
 	if os.Getenv("NOCACHE") == "1" {
-		router.noCache = true
+		router.core.noCache = true
 	}
 
 	if os.Getenv("NOHTML") == "1" {
-		router.htmlDisabled = true
+		router.core.htmlDisabled = true
 	}
 
-	router.logger = log.New(os.Stderr, "tube: ", log.LstdFlags|log.Lmsgprefix)
-	router.logger.Println("router initialized")
+	router.core.logger = log.New(os.Stderr, "tube: ", log.LstdFlags|log.Lmsgprefix)
+	router.core.logger.Println("router initialized")
+	router.core.requestLog = &defaultRequestLog{router}
 
 	return router
 }
@@ -0,0 +1,101 @@
+package tube
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+)
+
+// minimal recreation of the pre-radix linear regex route table, kept only
+// so BenchmarkRegexRoute has something to compare BenchmarkRadixRoute
+// against
+type regexRoute struct {
+	method  string
+	pattern *regexp.Regexp
+}
+
+func buildRegexRoutes(n int) []regexRoute {
+	routes := make([]regexRoute, 0, n)
+	for i := 0; i < n; i++ {
+		routes = append(routes, regexRoute{
+			method:  "GET",
+			pattern: regexp.MustCompile(`^/users/([^/]+)/posts/([^/]+)$`),
+		})
+	}
+	return routes
+}
+
+func matchRegexRoutes(routes []regexRoute, method, url string) bool {
+	for _, rt := range routes {
+		if rt.method == method && rt.pattern.MatchString(url) {
+			return true
+		}
+	}
+	return false
+}
+
+func buildRadixRouter(n int) *Router {
+	router := NewRouter()
+	for i := 0; i < n; i++ {
+		router.GET("/users/@id/posts/@postId", func(d *Data) {})
+	}
+	return router
+}
+
+func BenchmarkRegexRoute(b *testing.B) {
+	routes := buildRegexRoutes(200)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		matchRegexRoutes(routes, "GET", "/users/42/posts/99")
+	}
+}
+
+func BenchmarkRadixRoute(b *testing.B) {
+	router := buildRadixRouter(200)
+	router.core.noCache = true // exercise the tree walk, not the cache
+	req := httptest.NewRequest("GET", "/users/42/posts/99", nil)
+	w := httptest.NewRecorder()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		router.ServeHTTP(w, req)
+	}
+}
+
+func TestRadixRouteMatch(t *testing.T) {
+	router := NewRouter()
+	var got Params
+	router.GET("/users/@id/posts/@postId", func(d *Data) {
+		got = d.Params
+	})
+
+	req := httptest.NewRequest("GET", "/users/42/posts/99", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if got["id"] != "42" || got["postId"] != "99" {
+		t.Fatalf("unexpected params: %+v", got)
+	}
+}
+
+func TestRadixRouteDir(t *testing.T) {
+	router := NewRouter()
+	called := ""
+	router.GET(router.Dir("/assets"), func(d *Data) {
+		called = d.P("path")
+	})
+
+	req := httptest.NewRequest("GET", "/assets/css/site.css", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if called != "css/site.css" {
+		t.Fatalf("expected wildcard path capture, got %q", called)
+	}
+
+	req = httptest.NewRequest("GET", "/assets", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code == http.StatusNotFound {
+		t.Fatalf("expected bare dir route to match")
+	}
+}
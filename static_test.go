@@ -0,0 +1,43 @@
+package tube
+
+import (
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+)
+
+func TestStaticFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"index.html":           {Data: []byte("home")},
+		"about.html":           {Data: []byte(`<!-- include "partials/footer.html" -->`)},
+		"partials/footer.html": {Data: []byte("footer")},
+	}
+
+	router := NewRouter()
+	router.StaticFS("/", fsys)
+
+	req := httptest.NewRequest("GET", "/about.html", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Body.String() != "footer" {
+		t.Fatalf("expected include to be resolved against fsys, got %q", w.Body.String())
+	}
+}
+
+func TestStaticFileFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"report.html": {Data: []byte("report body")},
+	}
+
+	router := NewRouter()
+	router.StaticFileFS("/report", "report.html", fsys)
+
+	req := httptest.NewRequest("GET", "/report", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Body.String() != "report body" {
+		t.Fatalf("expected file contents, got %q", w.Body.String())
+	}
+}
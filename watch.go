@@ -0,0 +1,15 @@
+//go:build !tube_watch
+
+package tube
+
+import "errors"
+
+// watches dir (recursively) and invalidates cached template renders that
+// depended on whatever file changed, so edits are picked up without a
+// restart. The real implementation needs github.com/fsnotify/fsnotify and
+// only builds with -tags tube_watch, so importing tube doesn't pull in
+// that dependency unless a consumer opts in; without the tag, WatchStatic
+// just reports that it isn't available in this build
+func (router *Router) WatchStatic(dir string) error {
+	return errors.New("tube: WatchStatic requires building with -tags tube_watch")
+}
@@ -0,0 +1,87 @@
+//go:build tube_watch
+
+package tube
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// watches dir (recursively) and invalidates cached template renders that
+// depended on whatever file changed, so edits are picked up without a
+// restart. A no-op when caching is already disabled (NOCACHE env var, or
+// noCache set directly), since nothing is cached to invalidate
+func (router *Router) WatchStatic(dir string) error {
+	if router.core.noCache {
+		return nil
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+
+	root, err := filepath.Abs(dir)
+	if err != nil {
+		watcher.Close()
+		return err
+	}
+
+	err = filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return watcher.Add(p)
+		}
+		return nil
+	})
+	if err != nil {
+		watcher.Close()
+		return err
+	}
+
+	go router.runWatch(watcher, root)
+
+	return nil
+}
+
+func (router *Router) runWatch(watcher *fsnotify.Watcher, root string) {
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+
+			if event.Has(fsnotify.Create) {
+				if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+					watcher.Add(event.Name)
+				}
+			}
+
+			if event.Has(fsnotify.Write) || event.Has(fsnotify.Create) ||
+				event.Has(fsnotify.Rename) || event.Has(fsnotify.Remove) {
+				router.handleWatchEvent(root, event.Name)
+			}
+		case _, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+		}
+	}
+}
+
+// translates an fsnotify event's absolute path back into the fsys-relative
+// path dependencies are tracked under, and invalidates any cached template
+// that depended on it
+func (router *Router) handleWatchEvent(root, name string) {
+	rel, err := filepath.Rel(root, name)
+	if err != nil {
+		return
+	}
+	router.invalidateHTMLDeps(filepath.ToSlash(rel))
+}
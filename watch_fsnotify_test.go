@@ -0,0 +1,49 @@
+//go:build tube_watch
+
+package tube
+
+import (
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWatchStaticInvalidatesOnChange(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, body string) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(body), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("about.html", "v1")
+
+	router := NewRouter()
+	router.StaticFS("/", os.DirFS(dir))
+
+	if err := router.WatchStatic(dir); err != nil {
+		t.Fatalf("WatchStatic: %s", err)
+	}
+
+	get := func() string {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", "/about.html", nil))
+		return w.Body.String()
+	}
+
+	if got := get(); got != "v1" {
+		t.Fatalf("expected v1, got %q", got)
+	}
+
+	write("about.html", "v2")
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if get() == "v2" {
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatalf("expected cache to be invalidated after file change, last saw %q", get())
+}
@@ -0,0 +1,12 @@
+//go:build !tube_watch
+
+package tube
+
+import "testing"
+
+func TestWatchStaticUnavailableWithoutBuildTag(t *testing.T) {
+	router := NewRouter()
+	if err := router.WatchStatic(t.TempDir()); err == nil {
+		t.Fatal("expected WatchStatic to report itself unavailable without -tags tube_watch")
+	}
+}
@@ -0,0 +1,62 @@
+package tube
+
+import (
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+)
+
+func TestInvalidateHTMLDepsClearsDependentPages(t *testing.T) {
+	fsys := fstest.MapFS{
+		"about.html":           {Data: []byte(`<!-- include "partials/footer.html" -->`)},
+		"partials/footer.html": {Data: []byte("footer v1")},
+	}
+
+	router := NewRouter()
+	router.StaticFS("/", fsys)
+
+	req := httptest.NewRequest("GET", "/about.html", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Body.String() != "footer v1" {
+		t.Fatalf("expected footer v1, got %q", w.Body.String())
+	}
+
+	// update the include in place, but the render is still cached
+	fsys["partials/footer.html"] = &fstest.MapFile{Data: []byte("footer v2")}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/about.html", nil))
+	if w.Body.String() != "footer v1" {
+		t.Fatalf("expected cached footer v1 before invalidation, got %q", w.Body.String())
+	}
+
+	router.invalidateHTMLDeps("partials/footer.html")
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/about.html", nil))
+	if w.Body.String() != "footer v2" {
+		t.Fatalf("expected footer v2 after invalidation, got %q", w.Body.String())
+	}
+}
+
+func TestEmptyCacheClearsHTMLDeps(t *testing.T) {
+	fsys := fstest.MapFS{
+		"about.html":           {Data: []byte(`<!-- include "partials/footer.html" -->`)},
+		"partials/footer.html": {Data: []byte("footer")},
+	}
+
+	router := NewRouter()
+	router.StaticFS("/", fsys)
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/about.html", nil))
+
+	if len(router.core.htmlDeps) == 0 {
+		t.Fatal("expected rendering /about.html to record htmlDeps")
+	}
+
+	router.EmptyCache()
+
+	if len(router.core.htmlDeps) != 0 {
+		t.Fatalf("expected EmptyCache to clear htmlDeps, got %v", router.core.htmlDeps)
+	}
+}